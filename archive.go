@@ -0,0 +1,107 @@
+package main
+
+/*
+* Read-only archive backends: tar:// and zip://. Archives don't support
+* the random Stat/ReadDir access pattern the walker needs without
+* indexing them first, so both decode the whole archive into an
+* in-memory fakeFS once at open time and hand that back as the
+* Filesystem -- "backup" (or "original") can be an archive file without
+* the rest of vfy needing to know.
+*/
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// openTarFS decodes every entry in the tar archive at path into an
+// in-memory fakeFS.
+func openTarFS(path string) (Filesystem, error) {
+	f, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	fs := newFakeFS(path)
+	fs.scheme = "tar"
+
+	tr := tar.NewReader(f)
+
+	for {
+		hdr, err := tr.Next()
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("tar://%s: %w", path, err)
+		}
+
+		name := strings.TrimSuffix(hdr.Name, "/")
+
+		if hdr.Typeflag == tar.TypeDir {
+			fs.Put(name, true, nil)
+			continue
+		}
+
+		data, err := ioutil.ReadAll(tr)
+
+		if err != nil {
+			return nil, fmt.Errorf("tar://%s: %s: %w", path, hdr.Name, err)
+		}
+
+		fs.Put(name, false, data)
+	}
+
+	return fs, nil
+}
+
+// openZipFS decodes every entry in the zip archive at path into an
+// in-memory fakeFS.
+func openZipFS(path string) (Filesystem, error) {
+	r, err := zip.OpenReader(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	fs := newFakeFS(path)
+	fs.scheme = "zip"
+
+	for _, zf := range r.File {
+		name := strings.TrimSuffix(zf.Name, "/")
+
+		if zf.FileInfo().IsDir() {
+			fs.Put(name, true, nil)
+			continue
+		}
+
+		rc, err := zf.Open()
+
+		if err != nil {
+			return nil, fmt.Errorf("zip://%s: %s: %w", path, zf.Name, err)
+		}
+
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+
+		if err != nil {
+			return nil, fmt.Errorf("zip://%s: %s: %w", path, zf.Name, err)
+		}
+
+		fs.Put(name, false, data)
+	}
+
+	return fs, nil
+}
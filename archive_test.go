@@ -0,0 +1,129 @@
+package main
+
+/*
+* Exercises the tar:// and zip:// backends (archive.go) end to end:
+* write a real archive to a temp dir, open it through openFilesystem,
+* and compare it against a fakeFS with the same contents.
+*/
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestTar(t *testing.T, path string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+
+	if err != nil {
+		t.Fatalf("create %s: %v", path, err)
+	}
+
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+
+	data := []byte("hello")
+
+	if err := tw.WriteHeader(&tar.Header{Name: "a.txt", Size: int64(len(data)), Mode: 0644}); err != nil {
+		t.Fatalf("write tar header: %v", err)
+	}
+
+	if _, err := tw.Write(data); err != nil {
+		t.Fatalf("write tar data: %v", err)
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar writer: %v", err)
+	}
+}
+
+func TestOpenTarFS(t *testing.T) {
+	resetGlobalState()
+
+	tarPath := filepath.Join(t.TempDir(), "backup.tar")
+	writeTestTar(t, tarPath)
+
+	fs, err := openFilesystem("tar://" + tarPath)
+
+	if err != nil {
+		t.Fatalf("openFilesystem: %v", err)
+	}
+
+	if fs.Type() != "tar" {
+		t.Errorf("Type() = %q, want %q", fs.Type(), "tar")
+	}
+
+	orig := newFakeFS("orig")
+	orig.Put("a.txt", false, []byte("hello"))
+
+	compare(orig, fs, "")
+
+	if globalResultSummary.diffCount != 0 {
+		t.Errorf("diffCount = %d, want 0", globalResultSummary.diffCount)
+	}
+}
+
+func writeTestZip(t *testing.T, path string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+
+	if err != nil {
+		t.Fatalf("create %s: %v", path, err)
+	}
+
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+
+	w, err := zw.Create("a.txt")
+
+	if err != nil {
+		t.Fatalf("create zip entry: %v", err)
+	}
+
+	if _, err := w.Write([]byte("hello")); err != nil {
+		t.Fatalf("write zip data: %v", err)
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip writer: %v", err)
+	}
+}
+
+func TestOpenZipFS(t *testing.T) {
+	resetGlobalState()
+
+	zipPath := filepath.Join(t.TempDir(), "backup.zip")
+	writeTestZip(t, zipPath)
+
+	fs, err := openFilesystem("zip://" + zipPath)
+
+	if err != nil {
+		t.Fatalf("openFilesystem: %v", err)
+	}
+
+	if fs.Type() != "zip" {
+		t.Errorf("Type() = %q, want %q", fs.Type(), "zip")
+	}
+
+	orig := newFakeFS("orig")
+	orig.Put("a.txt", false, []byte("hello"))
+
+	compare(orig, fs, "")
+
+	if globalResultSummary.diffCount != 0 {
+		t.Errorf("diffCount = %d, want 0", globalResultSummary.diffCount)
+	}
+}
+
+func TestOpenFilesystemUnimplementedBackend(t *testing.T) {
+	if _, err := openFilesystem("s3://some-bucket"); err == nil {
+		t.Error("expected an error opening s3://, got nil")
+	}
+}
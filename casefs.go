@@ -0,0 +1,92 @@
+package main
+
+/*
+* Case-insensitive-filesystem safety mode.
+*
+* Some destinations (Windows, macOS/HFS+, exFAT) collapse names that only
+* differ by case. If "original" contains both "Foo.txt" and "foo.txt" but
+* "backup" lives on one of these filesystems, one of the two silently
+* overwrote the other during the backup and vfy would otherwise report a
+* clean match. --case-sensitive-fs lets the caller state the backup's
+* behavior, or have it auto-detected.
+*/
+
+import (
+	"io/ioutil"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+
+	flag "github.com/ogier/pflag"
+)
+
+var caseSensitiveFS = flag.StringP("case-sensitive-fs", "", "auto",
+	"Is <backupDir> on a case-sensitive filesystem? auto|true|false [default: auto]")
+
+// backupIsCaseInsensitive resolves --case-sensitive-fs into a yes/no answer
+// for fs (the backup side). "auto" probes a real local filesystem by
+// creating a temp file and stat'ing its uppercase variant; for any other
+// backend, or if the probe can't be performed, it falls back to a
+// per-OS guess. Called once per run (newWalker caches the result on the
+// walker), since the "auto" probe creates and removes a file under the
+// backup root and has no business running once per directory.
+func backupIsCaseInsensitive(fs Filesystem) bool {
+	switch strings.ToLower(*caseSensitiveFS) {
+	case "true":
+		return false
+	case "false":
+		return true
+	}
+
+	if local, ok := fs.(*localFS); ok {
+		if insensitive, ok := probeCaseInsensitive(local.root); ok {
+			return insensitive
+		}
+	}
+
+	return runtime.GOOS == "windows" || runtime.GOOS == "darwin"
+}
+
+// probeCaseInsensitive creates a throwaway file under root and checks
+// whether its all-uppercase name resolves to the same file.
+func probeCaseInsensitive(root string) (insensitive bool, ok bool) {
+	probe, err := ioutil.TempFile(root, "vfy-case-probe-")
+
+	if err != nil {
+		return false, false
+	}
+
+	probeName := probe.Name()
+	probe.Close()
+	defer os.Remove(probeName)
+
+	_, statErr := os.Stat(strings.ToUpper(probeName))
+
+	return statErr == nil, true
+}
+
+// detectCaseConflicts scans one directory's already-fetched listing for
+// names that only differ by case (e.g. "Foo.txt" and "foo.txt"). Each
+// conflicting group is reported as CASECONF and counted, since an
+// insensitive backup can only have kept one of them.
+func detectCaseConflicts(fs Filesystem, relative string, names []string) {
+	byLower := make(map[string][]string)
+
+	for _, name := range names {
+		lower := strings.ToLower(name)
+		byLower[lower] = append(byLower[lower], name)
+	}
+
+	for _, group := range byLower {
+		if len(group) < 2 {
+			continue
+		}
+
+		safePrintf("CASECONF: [%s%s] has case-colliding names that can't all "+
+			"survive on an insensitive backup: %v \n", fs.URI(), relative, group)
+
+		atomic.AddInt64(&globalResultSummary.diffCount, 1)
+		atomic.AddInt64(&globalResultSummary.caseConflict, 1)
+	}
+}
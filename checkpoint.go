@@ -0,0 +1,153 @@
+package main
+
+/*
+* Resumable runs. main() already installed a SIGINT handler that did
+* nothing with it; this wires it up: on Ctrl-C, the current
+* resultSummary and the outcome (matched or not) of every relative path
+* already compared are written to --checkpoint FILE as JSON. A later run
+* started with --resume FILE loads that back in: a previously-matched
+* path skips sameFile/compareByHash entirely, while a previously-known
+* diff still skips the expensive comparison but is re-emitted and
+* recounted, so a resumed run never reports fewer differences than a
+* full one would.
+*
+* The checkpoint's saved summary is NOT folded back into the resumed
+* run: visit() always walks the whole tree and recounts every item
+* regardless of --resume (only the per-file sameFile/compareByHash call
+* is skipped for paths already in processedPaths), so a resumed run's
+* own summary is already a complete, correct count on its own --
+* adding the checkpoint's numbers on top would double-count every
+* resumed item.
+*/
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	flag "github.com/ogier/pflag"
+)
+
+var checkpointPath = flag.StringP("checkpoint", "", "",
+	"On interrupt, write resultSummary and processed paths here as JSON")
+var resumePath = flag.StringP("resume", "", "",
+	"Resume a previous run: skip paths --checkpoint already recorded as processed")
+
+// checkpointSummary mirrors resultSummary with exported fields, since
+// resultSummary's own fields are unexported (atomic.AddInt64 needs a
+// pointer to the field, not a method call) and so can't be marshaled
+// directly.
+type checkpointSummary struct {
+	DiffCount    int64  `json:"diffCount"`
+	ItemCount    int64  `json:"itemCount"`
+	SkippedCount int64  `json:"skippedCount"`
+	ErrorCount   int64  `json:"errorCount"`
+	SymLinkError int64  `json:"symLinkError"`
+	SymMisMatch  int64  `json:"symMisMatch"`
+	CaseConflict int64  `json:"caseConflict"`
+	HashMismatch int64  `json:"hashMismatch"`
+	ExtraCount   int64  `json:"extraCount"`
+}
+
+type checkpointFile struct {
+	Summary   checkpointSummary `json:"summary"`
+	Processed map[string]bool `json:"processed"`
+}
+
+var processedMu sync.Mutex
+
+// processedPaths maps a compared relative path to whether it matched.
+// The value matters as much as the key: a resumed run must still know
+// that a path was a known diff, not just that it was "done", or it'll
+// launder that diff into a match.
+var processedPaths = make(map[string]bool)
+
+// markProcessed records relative as compared, along with whether it
+// matched, so a checkpoint taken after this point, or a later --resume
+// run, knows both that it doesn't need comparing again and what the
+// comparison found.
+func markProcessed(relative string, matched bool) {
+	processedMu.Lock()
+	processedPaths[relative] = matched
+	processedMu.Unlock()
+}
+
+// alreadyProcessed reports whether relative was already compared, and
+// if so, whether it matched.
+func alreadyProcessed(relative string) (matched bool, ok bool) {
+	processedMu.Lock()
+	defer processedMu.Unlock()
+	matched, ok = processedPaths[relative]
+	return matched, ok
+}
+
+// loadCheckpoint reads *resumePath, if set, populating processedPaths so
+// the resumed walk skips comparing anything already recorded.
+func loadCheckpoint() error {
+	if *resumePath == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(*resumePath)
+
+	if err != nil {
+		return err
+	}
+
+	var cp checkpointFile
+
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return err
+	}
+
+	processedMu.Lock()
+	for p, matched := range cp.Processed {
+		processedPaths[p] = matched
+	}
+	processedMu.Unlock()
+
+	return nil
+}
+
+// writeCheckpoint flushes the current resultSummary and processed-paths
+// set to *checkpointPath. Called from the SIGINT handler in main, so the
+// summary fields -- still being mutated by live compareWorker/visit
+// goroutines -- are read with atomic.LoadInt64 rather than plain field
+// reads.
+func writeCheckpoint() error {
+	if *checkpointPath == "" {
+		return nil
+	}
+
+	processedMu.Lock()
+	processed := make(map[string]bool, len(processedPaths))
+
+	for p, matched := range processedPaths {
+		processed[p] = matched
+	}
+	processedMu.Unlock()
+
+	cp := checkpointFile{
+		Summary: checkpointSummary{
+			DiffCount:    atomic.LoadInt64(&globalResultSummary.diffCount),
+			ItemCount:    atomic.LoadInt64(&globalResultSummary.itemCount),
+			SkippedCount: atomic.LoadInt64(&globalResultSummary.skippedCount),
+			ErrorCount:   atomic.LoadInt64(&globalResultSummary.errorCount),
+			SymLinkError: atomic.LoadInt64(&globalResultSummary.symLinkError),
+			SymMisMatch:  atomic.LoadInt64(&globalResultSummary.symMisMatch),
+			CaseConflict: atomic.LoadInt64(&globalResultSummary.caseConflict),
+			HashMismatch: atomic.LoadInt64(&globalResultSummary.hashMismatch),
+			ExtraCount:   atomic.LoadInt64(&globalResultSummary.extraCount),
+		},
+		Processed: processed,
+	}
+
+	data, err := json.MarshalIndent(cp, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(*checkpointPath, data, 0644)
+}
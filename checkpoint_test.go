@@ -0,0 +1,47 @@
+package main
+
+/*
+* Exercises checkpoint.go's resume path against fakeFS pairs: a path
+* already recorded as a known diff must stay a diff on resume, never get
+* laundered into a match just because it was "processed".
+*/
+
+import "testing"
+
+func TestResumeSkipsMatchWithoutRecompare(t *testing.T) {
+	resetGlobalState()
+
+	orig := newFakeFS("orig")
+	orig.Put("a.txt", false, []byte("hello"))
+
+	backup := newFakeFS("backup")
+	backup.Put("a.txt", false, []byte("hello"))
+
+	markProcessed("a.txt", true)
+
+	compare(orig, backup, "")
+
+	if globalResultSummary.diffCount != 0 {
+		t.Errorf("diffCount = %d, want 0 (a.txt was already known to match)", globalResultSummary.diffCount)
+	}
+}
+
+func TestResumeRecountsKnownDiffInsteadOfLaunderingIt(t *testing.T) {
+	resetGlobalState()
+
+	orig := newFakeFS("orig")
+	orig.Put("a.txt", false, []byte("hello"))
+
+	backup := newFakeFS("backup")
+	backup.Put("a.txt", false, []byte("goodbye"))
+
+	// Simulate resuming from a checkpoint that recorded a.txt as a known
+	// diff, e.g. taken right after a previous run's sameFile failed.
+	markProcessed("a.txt", false)
+
+	compare(orig, backup, "")
+
+	if globalResultSummary.diffCount != 1 {
+		t.Errorf("diffCount = %d, want 1 (a.txt was already known to differ, and must stay reported)", globalResultSummary.diffCount)
+	}
+}
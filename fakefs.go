@@ -0,0 +1,204 @@
+package main
+
+/*
+* fakeFS is an in-memory Filesystem backend, addressed as fake://<name>.
+* It exists so that vfy's own walker logic can eventually be exercised
+* without touching real disk; right now it's just a bare-bones tree of
+* fakeFileInfo entries plus byte contents, enough for Stat/ReadDir/Open.
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+type fakeEntry struct {
+	name    string
+	dir     bool
+	symlink string // non-empty if this entry is a symlink to another path
+	data    []byte
+	mode    os.FileMode
+	modTime time.Time
+}
+
+type fakeFileInfo struct {
+	entry fakeEntry
+}
+
+func (fi fakeFileInfo) Name() string       { return filepath.Base(fi.entry.name) }
+func (fi fakeFileInfo) Size() int64        { return int64(len(fi.entry.data)) }
+func (fi fakeFileInfo) ModTime() time.Time { return fi.entry.modTime }
+func (fi fakeFileInfo) IsDir() bool        { return fi.entry.dir }
+func (fi fakeFileInfo) Sys() interface{}   { return nil }
+
+func (fi fakeFileInfo) Mode() os.FileMode {
+	if fi.entry.symlink != "" {
+		return os.ModeSymlink
+	}
+
+	if fi.entry.dir {
+		return os.ModeDir | 0755
+	}
+
+	if fi.entry.mode != 0 {
+		return fi.entry.mode
+	}
+
+	return 0644
+}
+
+// fakeFS is a named, in-memory tree of paths to fakeEntry values. Tests
+// build one directly with newFakeFS and Put, rather than going through a
+// fake:// URI. scheme overrides the URI/Type reported by an instance
+// built to back a real archive backend (tar://, zip://); empty means the
+// ordinary "fake" backend.
+type fakeFS struct {
+	name    string
+	entries map[string]fakeEntry
+	scheme  string
+}
+
+func newFakeFS(name string) *fakeFS {
+	return &fakeFS{
+		// Keyed by ".", matching what filepath.Clean("") normalizes the
+		// root path to -- every lookup below cleans its path first, so
+		// the root entry has to live under the same key or Stat/ReadDir
+		// on "" can never find it.
+		name:    name,
+		entries: map[string]fakeEntry{".": {name: ".", dir: true}},
+	}
+}
+
+// Put registers a file (or, with dir=true, a directory) at path with the
+// given contents. Parent directories are created implicitly.
+func (fs *fakeFS) Put(path string, dir bool, data []byte) {
+	path = filepath.Clean(path)
+
+	fs.entries[path] = fakeEntry{
+		name:    path,
+		dir:     dir,
+		data:    data,
+		modTime: time.Now(),
+	}
+
+	fs.ensureParents(path)
+}
+
+// Symlink registers path as a symlink pointing at target.
+func (fs *fakeFS) Symlink(path, target string) {
+	path = filepath.Clean(path)
+
+	fs.entries[path] = fakeEntry{name: path, symlink: target, modTime: time.Now()}
+
+	fs.ensureParents(path)
+}
+
+// ensureParents makes sure every ancestor directory of path has an entry
+// of its own, so a single Put("a/b/c.txt", ...) is enough to make "a"
+// and "a/b" show up in ReadDir -- matching Put's "parent directories are
+// created implicitly" doc comment, which the root-only entries map
+// didn't actually honor before this.
+func (fs *fakeFS) ensureParents(path string) {
+	for {
+		parent := filepath.Dir(path)
+
+		if parent == path {
+			return
+		}
+
+		if _, ok := fs.entries[parent]; !ok {
+			fs.entries[parent] = fakeEntry{name: parent, dir: true, modTime: time.Now()}
+		}
+
+		path = parent
+	}
+}
+
+func (fs *fakeFS) Stat(path string) (os.FileInfo, error) {
+	return fs.Lstat(path)
+}
+
+func (fs *fakeFS) Lstat(path string) (os.FileInfo, error) {
+	path = filepath.Clean(path)
+
+	entry, ok := fs.entries[path]
+
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: path, Err: os.ErrNotExist}
+	}
+
+	return fakeFileInfo{entry: entry}, nil
+}
+
+func (fs *fakeFS) Open(path string) (io.ReadCloser, error) {
+	path = filepath.Clean(path)
+
+	entry, ok := fs.entries[path]
+
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+
+	return nopCloser{bytes.NewReader(entry.data)}, nil
+}
+
+func (fs *fakeFS) ReadDir(path string) ([]os.FileInfo, error) {
+	path = filepath.Clean(path)
+
+	if _, ok := fs.entries[path]; !ok {
+		return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+	}
+
+	var infos []os.FileInfo
+
+	for p, entry := range fs.entries {
+		if p == path || filepath.Dir(p) != path {
+			continue
+		}
+
+		infos = append(infos, fakeFileInfo{entry: entry})
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+
+	return infos, nil
+}
+
+func (fs *fakeFS) EvalSymlinks(path string) (string, error) {
+	path = filepath.Clean(path)
+
+	entry, ok := fs.entries[path]
+
+	if !ok {
+		return "", &os.PathError{Op: "lstat", Path: path, Err: os.ErrNotExist}
+	}
+
+	if entry.symlink == "" {
+		return path, nil
+	}
+
+	return entry.symlink, nil
+}
+
+func (fs *fakeFS) URI() string {
+	return fmt.Sprintf("%s://%s/", fs.Type(), fs.name)
+}
+
+func (fs *fakeFS) Type() string {
+	if fs.scheme == "" {
+		return "fake"
+	}
+
+	return fs.scheme
+}
+
+type nopCloser struct {
+	io.Reader
+}
+
+func (nopCloser) Close() error { return nil }
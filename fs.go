@@ -0,0 +1,116 @@
+package main
+
+/*
+* Filesystem abstraction used by the walker so that "original" and "backup"
+* do not both have to be real directories on the local disk. Each side of a
+* comparison is opened from a URI (file://, fake://, tar://, zip://, and
+* s3://, sftp:// reserved for future backends) and handed around as a
+* Filesystem.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Filesystem is the set of operations the walker needs from either side of
+// a comparison. Implementations wrap a real disk, an archive, a remote
+// bucket, or (for tests) an in-memory tree.
+type Filesystem interface {
+	Stat(path string) (os.FileInfo, error)
+	Lstat(path string) (os.FileInfo, error)
+	Open(path string) (io.ReadCloser, error)
+	ReadDir(path string) ([]os.FileInfo, error)
+	EvalSymlinks(path string) (string, error)
+
+	// URI returns the location this Filesystem was opened from, for
+	// printing in error/debug messages.
+	URI() string
+
+	// Type returns a short backend name ("local", "fake", ...).
+	Type() string
+}
+
+// localFS is a Filesystem rooted at a real directory on disk. This is what
+// vfy has always used; it's now just one of several backends.
+type localFS struct {
+	root string
+	uri  string
+}
+
+func newLocalFS(root string) *localFS {
+	return &localFS{root: root, uri: "file://" + strings.TrimSuffix(root, "/") + "/"}
+}
+
+func (fs *localFS) full(path string) string {
+	return filepath.Join(fs.root, path)
+}
+
+func (fs *localFS) Stat(path string) (os.FileInfo, error) {
+	return os.Stat(fs.full(path))
+}
+
+func (fs *localFS) Lstat(path string) (os.FileInfo, error) {
+	return os.Lstat(fs.full(path))
+}
+
+func (fs *localFS) Open(path string) (io.ReadCloser, error) {
+	return os.Open(fs.full(path))
+}
+
+func (fs *localFS) ReadDir(path string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(fs.full(path))
+}
+
+func (fs *localFS) EvalSymlinks(path string) (string, error) {
+	return filepath.EvalSymlinks(fs.full(path))
+}
+
+func (fs *localFS) URI() string {
+	return fs.uri
+}
+
+func (fs *localFS) Type() string {
+	return "local"
+}
+
+// openFilesystem parses a URI of the form "scheme://location" and returns
+// the Filesystem backend for it. A URI with no "://" is treated as a plain
+// local path, for backwards compatibility with `vfy <origDir> <backupDir>`.
+func openFilesystem(uri string) (Filesystem, error) {
+	scheme, location, ok := splitSchemeURI(uri)
+
+	if !ok {
+		return newLocalFS(uri), nil
+	}
+
+	switch scheme {
+	case "file":
+		return newLocalFS(location), nil
+	case "fake":
+		return newFakeFS(location), nil
+	case "tar":
+		return openTarFS(location)
+	case "zip":
+		return openZipFS(location)
+	case "s3", "sftp":
+		return nil, fmt.Errorf("%s:// backend is not implemented yet", scheme)
+	default:
+		return nil, errors.New("unknown backend scheme: " + scheme)
+	}
+}
+
+func splitSchemeURI(uri string) (scheme, location string, ok bool) {
+	idx := strings.Index(uri, "://")
+
+	if idx == -1 {
+		return "", "", false
+	}
+
+	return uri[:idx], uri[idx+len("://"):], true
+}
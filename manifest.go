@@ -0,0 +1,144 @@
+package main
+
+/*
+* Manifest file: a stable text cache of `"<relpath>" <size> <mtime> <hash>`
+* for the original side of a --hash run, one line per file, so a repeat
+* run can skip re-hashing anything whose size and mtime haven't moved.
+* Entries are keyed by relpath and guarded by manifestMu since they're
+* read and written from every walker worker.
+*
+* relpath is written Go-quoted (%q) rather than bare, since a plain
+* space-separated format can't tell a path containing a space (e.g.
+* "My Photos/img 1.jpg") apart from the delimiters around size/mtime/hash.
+*/
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	flag "github.com/ogier/pflag"
+)
+
+var manifestPath = flag.StringP("manifest", "", "",
+	"Path to a manifest file caching original-side hashes across runs")
+
+type manifestEntry struct {
+	size  int64
+	mtime int64
+	hash  string
+}
+
+var manifestMu sync.Mutex
+var manifest map[string]manifestEntry
+
+// loadManifest reads *manifestPath, if set, into the in-memory manifest
+// map. A missing file just means this is the first run; that's not an
+// error.
+func loadManifest() error {
+	manifest = make(map[string]manifestEntry)
+
+	if *manifestPath == "" {
+		return nil
+	}
+
+	f, err := os.Open(*manifestPath)
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		quoted, err := strconv.QuotedPrefix(line)
+
+		if err != nil {
+			continue
+		}
+
+		relPath, err := strconv.Unquote(quoted)
+
+		if err != nil {
+			continue
+		}
+
+		fields := strings.Fields(line[len(quoted):])
+
+		if len(fields) != 3 {
+			continue
+		}
+
+		size, sizeErr := strconv.ParseInt(fields[0], 10, 64)
+		mtime, mtimeErr := strconv.ParseInt(fields[1], 10, 64)
+
+		if sizeErr != nil || mtimeErr != nil {
+			continue
+		}
+
+		manifest[relPath] = manifestEntry{size: size, mtime: mtime, hash: fields[2]}
+	}
+
+	return scanner.Err()
+}
+
+// saveManifest writes the in-memory manifest back to *manifestPath,
+// overwriting it. Called once, after the walk has finished.
+func saveManifest() error {
+	if *manifestPath == "" {
+		return nil
+	}
+
+	f, err := os.Create(*manifestPath)
+
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	for relPath, entry := range manifest {
+		fmt.Fprintf(w, "%q %d %d %s\n", relPath, entry.size, entry.mtime, entry.hash)
+	}
+
+	return w.Flush()
+}
+
+// cachedOrigHash returns a manifest-cached hash for relPath if its size
+// and mtime still match what's recorded, so the caller can skip re-hashing
+// the original side entirely.
+func cachedOrigHash(relPath string, size, mtime int64) (string, bool) {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	entry, ok := manifest[relPath]
+
+	if !ok || entry.size != size || entry.mtime != mtime {
+		return "", false
+	}
+
+	return entry.hash, true
+}
+
+func updateManifest(relPath string, size, mtime int64, digest string) {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+
+	manifest[relPath] = manifestEntry{size: size, mtime: mtime, hash: digest}
+}
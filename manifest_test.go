@@ -0,0 +1,44 @@
+package main
+
+/*
+* Exercises the manifest save/load round trip (manifest.go), in
+* particular relpaths containing spaces -- the format used to be plain
+* space-separated fields, which silently dropped any such entry.
+*/
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestManifestRoundTripPathWithSpace(t *testing.T) {
+	manifest = make(map[string]manifestEntry)
+
+	path := "My Photos/img 1.jpg"
+	updateManifest(path, 123, 456, "deadbeef")
+
+	dir := t.TempDir()
+	old := *manifestPath
+	*manifestPath = filepath.Join(dir, "manifest.txt")
+	defer func() { *manifestPath = old }()
+
+	if err := saveManifest(); err != nil {
+		t.Fatalf("saveManifest: %v", err)
+	}
+
+	manifest = nil
+
+	if err := loadManifest(); err != nil {
+		t.Fatalf("loadManifest: %v", err)
+	}
+
+	hash, ok := cachedOrigHash(path, 123, 456)
+
+	if !ok {
+		t.Fatalf("cachedOrigHash(%q) missing after reload", path)
+	}
+
+	if hash != "deadbeef" {
+		t.Errorf("hash = %q, want %q", hash, "deadbeef")
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+/*
+* Structured output layer. Every comparison result that used to only be a
+* stdout line can now also be routed to:
+*
+*   --combined FILE       every result, one line per path, prefixed by a
+*                          sigil: = match, * differ, + only-in-original,
+*                          ! error, ~ symlink-mismatch.
+*   --missing-on-dst FILE just the "+" paths
+*   --differ FILE         just the "*" and "~" paths
+*   --match FILE          just the "=" paths
+*   --error FILE          just the "!" paths
+*   --output=json         one JSON object per event on stdout, e.g.
+*                          {"kind":"FILE","path":"...","reason":"size", ...}
+*
+* --machine is just the old summary-only facet of this; the event-level
+* detail is new.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	flag "github.com/ogier/pflag"
+)
+
+const (
+	sigilMatch   = "="
+	sigilDiffer  = "*"
+	sigilMissing = "+"
+	sigilError   = "!"
+	sigilSymlink = "~"
+)
+
+var outputFormat = flag.StringP("output", "", "text", "Result output format: text|json [default: text]")
+var combinedFile = flag.StringP("combined", "", "", "Write every result as a sigil-prefixed path to FILE")
+var missingOnDstFile = flag.StringP("missing-on-dst", "", "", "Write paths missing from <backupDir> to FILE")
+var differFile = flag.StringP("differ", "", "", "Write paths that differ to FILE")
+var matchFile = flag.StringP("match", "", "", "Write paths that match to FILE")
+var errorFile = flag.StringP("error", "", "", "Write paths that errored to FILE")
+
+// resultEvent is what --output=json marshals, one per line.
+type resultEvent struct {
+	Kind       string `json:"kind"`
+	Path       string `json:"path"`
+	Reason     string `json:"reason,omitempty"`
+	OrigSize   int64  `json:"origSize,omitempty"`
+	BackupSize int64  `json:"backupSize,omitempty"`
+}
+
+type outputSinks struct {
+	mu                                                sync.Mutex
+	combined, missingOnDst, differ, match, errorSink io.WriteCloser
+}
+
+var sinks = &outputSinks{}
+
+func createSink(path string) (io.WriteCloser, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	return os.Create(path)
+}
+
+// openOutputSinks creates the files named by --combined/--missing-on-dst/
+// --differ/--match/--error, if any. Called once, from main.
+func openOutputSinks() error {
+	var err error
+
+	if sinks.combined, err = createSink(*combinedFile); err != nil {
+		return err
+	}
+
+	if sinks.missingOnDst, err = createSink(*missingOnDstFile); err != nil {
+		return err
+	}
+
+	if sinks.differ, err = createSink(*differFile); err != nil {
+		return err
+	}
+
+	if sinks.match, err = createSink(*matchFile); err != nil {
+		return err
+	}
+
+	if sinks.errorSink, err = createSink(*errorFile); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func closeOutputSinks() {
+	for _, w := range []io.WriteCloser{sinks.combined, sinks.missingOnDst, sinks.differ, sinks.match, sinks.errorSink} {
+		if w != nil {
+			w.Close()
+		}
+	}
+}
+
+// emitResult records one comparison outcome against the structured
+// output layer: the combined file (if set), the matching per-category
+// file (if set), and a JSON line on stdout when --output=json. It's safe
+// to call from any walker goroutine.
+func emitResult(kind, sigil, path, reason string, origSize, backupSize int64) {
+	sinks.mu.Lock()
+	defer sinks.mu.Unlock()
+
+	if sinks.combined != nil {
+		fmt.Fprintf(sinks.combined, "%s %s\n", sigil, path)
+	}
+
+	var categoryFile io.Writer
+
+	switch sigil {
+	case sigilMissing:
+		categoryFile = sinks.missingOnDst
+	case sigilDiffer, sigilSymlink:
+		categoryFile = sinks.differ
+	case sigilMatch:
+		categoryFile = sinks.match
+	case sigilError:
+		categoryFile = sinks.errorSink
+	}
+
+	if categoryFile != nil {
+		fmt.Fprintf(categoryFile, "%s\n", path)
+	}
+
+	if *outputFormat == "json" {
+		line, err := json.Marshal(resultEvent{
+			Kind:       kind,
+			Path:       path,
+			Reason:     reason,
+			OrigSize:   origSize,
+			BackupSize: backupSize,
+		})
+
+		if err == nil {
+			safePrintln(string(line))
+		}
+	}
+}
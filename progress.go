@@ -0,0 +1,101 @@
+package main
+
+/*
+* Live progress reporting. --progress controls whether a one-line
+* files/sec, MB/sec, ETA summary is written to stderr once a second while
+* the walker runs; "auto" (the default) shows it only when stdout is a
+* terminal, so piping output to a file or into another program doesn't
+* get progress lines mixed into it.
+*/
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+
+	flag "github.com/ogier/pflag"
+)
+
+var progressMode = flag.StringP("progress", "", "auto",
+	"Show files/sec, MB/sec, ETA on stderr: auto|always|never [default: auto]")
+
+var progressFilesDone int64
+var progressBytesDone int64
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+func progressEnabled() bool {
+	switch *progressMode {
+	case "always":
+		return true
+	case "never":
+		return false
+	default:
+		return isTerminal(os.Stdout)
+	}
+}
+
+// markProgress records one more file compared, size bytes, for the
+// progress reporter. Safe to call from any compare worker.
+func markProgress(size int64) {
+	atomic.AddInt64(&progressFilesDone, 1)
+	atomic.AddInt64(&progressBytesDone, size)
+}
+
+// reportProgress prints a files/sec, MB/sec, ETA line to stderr every
+// second until done is closed. total is the item count estimated by
+// countItems before the walk started; 0 means "unknown", so ETA is
+// omitted.
+func reportProgress(total int64, done <-chan struct{}) {
+	if !progressEnabled() {
+		<-done
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	start := time.Now()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			elapsed := time.Since(start).Seconds()
+
+			if elapsed <= 0 {
+				continue
+			}
+
+			filesDone := atomic.LoadInt64(&progressFilesDone)
+			bytesDone := atomic.LoadInt64(&progressBytesDone)
+			filesPerSec := float64(filesDone) / elapsed
+			mbPerSec := float64(bytesDone) / elapsed / (1024 * 1024)
+
+			eta := "unknown"
+
+			if total > 0 && filesPerSec > 0 {
+				remaining := total - filesDone
+
+				if remaining < 0 {
+					remaining = 0
+				}
+
+				eta = time.Duration(float64(remaining) / filesPerSec * float64(time.Second)).Round(time.Second).String()
+			}
+
+			fmt.Fprintf(os.Stderr, "PROGRESS: %d files (%.1f/s), %.1f MB (%.1f MB/s), ETA %s\n",
+				filesDone, filesPerSec, float64(bytesDone)/(1024*1024), mbPerSec, eta)
+		}
+	}
+}
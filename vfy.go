@@ -11,8 +11,14 @@ package main
 * "backup" (extra files in "backup" are ignored). If a file exists in both
 * "original" and "backup," they are compared by checking their lengths and by a
 * random sample of their contents, and the user is alerted if they differ.
-* 
-* Code based on https://github.com/defuse/backup-verify 
+*
+* Both sides are opened as a Filesystem (see fs.go), addressed by URI:
+* file://<path> for a real directory (the default if no scheme is given),
+* fake://<name> for an in-memory tree used in tests, and tar://, s3://,
+* sftp:// reserved for future backends. This means "original" and "backup"
+* don't both have to live on the local disk.
+*
+* Code based on https://github.com/defuse/backup-verify
 *
 * Output prefixes:
 * DIR - Directory in original missing from backup.
@@ -26,44 +32,52 @@ package main
 */
 
 import (
+	"bytes"
+	"crypto/rand"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	"math/big"
 	"os"
 	"os/signal"
-	"io"
-	"io/ioutil"
 	"path/filepath"
-	"bytes"
-	"crypto/rand"
-	"math/big"
-	"math"
+	"sync/atomic"
 	"syscall"
+
 	flag "github.com/ogier/pflag"
 )
 
 // the final report type
+//
+// Counters are int64 and mutated with sync/atomic because visit() (the
+// directory walk) and the parallel comparison workers (see walker.go) touch
+// them concurrently.
 type resultSummary struct {
-	diffCount    int
-	itemCount    int
-	skippedCount int
-	errorCount   int
-	symLinkError int
-	symMisMatch  int
+	diffCount    int64
+	itemCount    int64
+	skippedCount int64
+	errorCount   int64
+	symLinkError int64
+	symMisMatch  int64
+	caseConflict int64
+	hashMismatch int64
+	extraCount   int64
 	diffPct 	 string
 }
 
 
 func (r *resultSummary) getMachineText() string {
-	return fmt.Sprintf("SUMMARY: items: %d, diff:%d, diffpct:%s skip:%d, err:%d, symErr:%d, symMisMatchErr:%d ",
-		   r.itemCount, r.diffCount, r.diffPct, r.skippedCount, r.errorCount, r.symLinkError, r.symMisMatch )
+	return fmt.Sprintf("SUMMARY: items: %d, diff:%d, diffpct:%s skip:%d, err:%d, symErr:%d, symMisMatchErr:%d, caseConf:%d, hashMis:%d, extra:%d ",
+		   r.itemCount, r.diffCount, r.diffPct, r.skippedCount, r.errorCount, r.symLinkError, r.symMisMatch, r.caseConflict, r.hashMismatch, r.extraCount )
 
 }
 
 func (r *resultSummary) getHumanReadableText() string {
 	similarities := r.itemCount - r.diffCount
 
-	return fmt.Sprintf("\nSUMMARY: \n\t Items processed: %d \n\t Differences: %d (%s%%) \n\t Similarities: %d \n\t Skipped: %d \n\t Errors: %d \n\t Symlink Errors: %d \n\t SymMisMatch Errors: %d \n",
-		   r.itemCount, r.diffCount, r.diffPct, similarities, r.skippedCount, r.errorCount, r.symLinkError, r.symMisMatch )
+	return fmt.Sprintf("\nSUMMARY: \n\t Items processed: %d \n\t Differences: %d (%s%%) \n\t Similarities: %d \n\t Skipped: %d \n\t Errors: %d \n\t Symlink Errors: %d \n\t SymMisMatch Errors: %d \n\t Case Conflicts: %d \n\t Hash Mismatches: %d \n\t Extra In Backup: %d \n",
+		   r.itemCount, r.diffCount, r.diffPct, similarities, r.skippedCount, r.errorCount, r.symLinkError, r.symMisMatch, r.caseConflict, r.hashMismatch, r.extraCount )
 }
 
 
@@ -88,250 +102,293 @@ var help = flag.BoolP("help", "h", false, "Display this screen")
 // ------------------------------------------------------------------------ //
 
 
-func visit(relative string)  {
-
-
-	original := filepath.Join(userSource1, relative)
-	backup := filepath.Join(userSource2, relative)
+// visit walks relative in a single goroutine (the walker's own), doing the
+// cheap directory/symlink bookkeeping inline and handing each plain file
+// off to w's worker pool (see walker.go) for the possibly-expensive
+// sameFile comparison. Because its counters are shared with those
+// workers, every globalResultSummary update here goes through
+// sync/atomic.
+func visit(w *walker, relative string)  {
+	fsA, fsB := w.fsA, w.fsB
 
 	if *verbose {
-		fmt.Printf("DEBUG: Comparing [%s] to [%s] \n", original, backup)
+		safePrintf("DEBUG: Comparing [%s%s] to [%s%s] \n", fsA.URI(), relative, fsB.URI(), relative)
 	}
 
-//	if *ignoreDir != original || 
+//	if *ignoreDir != original ||
 	// Make sure both directories exist
-	file1Stat, file1Err := os.Stat(original)
-	file2Stat, file2Err := os.Stat(backup)
+	file1Stat, file1Err := fsA.Stat(relative)
+	file2Stat, file2Err := fsB.Stat(relative)
 
 	if file1Err != nil {
-		globalResultSummary.diffCount += 1
-		globalResultSummary.errorCount += 1
-		fmt.Printf("[%s] not a valid folder/file \n", original)
+		atomic.AddInt64(&globalResultSummary.diffCount, 1)
+		atomic.AddInt64(&globalResultSummary.errorCount, 1)
+		safePrintf("[%s%s] not a valid folder/file \n", fsA.URI(), relative)
+		emitResult("ERROR", sigilError, relative, file1Err.Error(), 0, 0)
 
 		return
 	}
-	
+
 	if file2Err != nil {
-		globalResultSummary.diffCount += 1
-		globalResultSummary.errorCount += 1
-		fmt.Printf("[%s] not a valid folder/file \n", backup)
+		atomic.AddInt64(&globalResultSummary.diffCount, 1)
+		atomic.AddInt64(&globalResultSummary.errorCount, 1)
+		safePrintf("[%s%s] not a valid folder/file \n", fsB.URI(), relative)
+		emitResult("ERROR", sigilError, relative, file2Err.Error(), 0, 0)
 
 		return
 	}
-	
+
 	// Stay on one filesystem if told to do so...
 
-	
+
 
 	// are both folders here?
 	if isDirOrFile(file1Stat) == "directory" {
 		if *verbose {
-			fmt.Print("\n In folder checking code\n\n")
+			safePrint("\n In folder checking code\n\n")
 		}
 
 		if isDirOrFile(file2Stat) != "directory"  {
-		fmt.Print(file2Err.Error())
+		safePrint(file2Err.Error())
 
 		if *verbose {
-			fmt.Print("\n In folder checking code: SECOND IF STATEMENT")
-			fmt.Printf("DIR [%s] not found in [%s]", original, backup)
+			safePrint("\n In folder checking code: SECOND IF STATEMENT")
+			safePrintf("DIR [%s%s] not found in [%s%s]", fsA.URI(), relative, fsB.URI(), relative)
 		}
 
-			globalResultSummary.diffCount += 1
-			
-			itemCount := countItems(original)
-			globalResultSummary.itemCount += itemCount
-			globalResultSummary.diffCount += itemCount
+			atomic.AddInt64(&globalResultSummary.diffCount, 1)
+			emitResult("DIR", sigilMissing, relative, "missing-on-backup", 0, 0)
+
+			itemCount := countItems(fsA, relative)
+			atomic.AddInt64(&globalResultSummary.itemCount, int64(itemCount))
+			atomic.AddInt64(&globalResultSummary.diffCount, int64(itemCount))
 
 			return
 		}
 
-		
-		// Read child folder's contents
-		files, folderReadErr := ioutil.ReadDir(original)
-		
-		if folderReadErr != nil {
-			globalResultSummary.diffCount += 1
-			globalResultSummary.errorCount += 1
-			fmt.Printf("Cannot read folder contents: [%s]  \n", original)
+
+		// March both sides' listings together (march.go): every name
+		// that exists on either side comes back once, carrying whichever
+		// of origInfo/backupInfo exist for it, so "missing on backup",
+		// "missing on original", and "on both" all fall out of the one
+		// loop below instead of a second ReadDir bolted on for --two-way.
+		entries, marchErr := marchChildren(fsA, fsB, relative)
+
+		if marchErr != nil {
+			atomic.AddInt64(&globalResultSummary.diffCount, 1)
+			atomic.AddInt64(&globalResultSummary.errorCount, 1)
+			safePrintf("Cannot read folder contents: [%s%s]  \n", fsA.URI(), relative)
+			emitResult("ERROR", sigilError, relative, marchErr.Error(), 0, 0)
 
 			return
 		}
 
-		for _, item := range files {
+		if w.backupInsensitive {
+			var names []string
 
-			if item.Name() == "." || item.Name() == ".." {
-				continue;
+			for _, entry := range entries {
+				if entry.origInfo != nil {
+					names = append(names, entry.name)
+				}
 			}
-			
-			globalResultSummary.itemCount += 1
 
-			origChildItemPath := filepath.Join(original, item.Name())
-			backupChildItemPath := filepath.Join(backup, item.Name())
-			
-			
+			detectCaseConflicts(fsA, relative, names)
+		}
+
+		for _, entry := range entries {
+
+			if entry.origInfo == nil {
+				// Exists in the backup only.
+				if *twoWay {
+					extraPath := filepath.Join(relative, entry.name)
+
+					atomic.AddInt64(&globalResultSummary.extraCount, 1)
+					safePrintf("EXTRA: [%s%s] exists in backup but not in original \n", fsB.URI(), extraPath)
+					emitResult("EXTRA", sigilExtra, extraPath, "only-in-backup", 0, 0)
+				}
+
+				continue
+			}
+
+			item := entry.origInfo
+
+			atomic.AddInt64(&globalResultSummary.itemCount, 1)
+
+			origChildItemPath := filepath.Join(relative, entry.name)
+			backupChildItemPath := origChildItemPath
+
+
 			// This check is independent of whether or not the path is
 			// a directory or a file. If either is a symlink, make sure they are
 			// both symlinks, and that they link to the same thing.
 
-			if isSymLink(origChildItemPath) || isSymLink(backupChildItemPath) {
-				if isSymLink(origChildItemPath) &&
-					isSymLink(backupChildItemPath) {
+			if isSymLink(fsA, origChildItemPath) || isSymLink(fsB, backupChildItemPath) {
+				if isSymLink(fsA, origChildItemPath) &&
+					isSymLink(fsB, backupChildItemPath) {
 
-					symlink1, symErr1 := filepath.EvalSymlinks(origChildItemPath);
-					symlink2, symErr2 := filepath.EvalSymlinks(backupChildItemPath);
+					symlink1, symErr1 := fsA.EvalSymlinks(origChildItemPath);
+					symlink2, symErr2 := fsB.EvalSymlinks(backupChildItemPath);
 
-					if symErr1 != nil {			
-						fmt.Printf("SYMMIS: Syslink read error [%s] \n",
-						origChildItemPath)
-						
-						globalResultSummary.symLinkError += 1
+					if symErr1 != nil {
+						safePrintf("SYMMIS: Syslink read error [%s%s] \n",
+						fsA.URI(), origChildItemPath)
+						emitResult("SYMMIS", sigilError, origChildItemPath, symErr1.Error(), 0, 0)
+
+						atomic.AddInt64(&globalResultSummary.symLinkError, 1)
 						return
 					}
-					
-					if symErr2 != nil {			
-						fmt.Printf("SYMMIS: Syslink read error [%s] \n",
-						backupChildItemPath)
 
-						globalResultSummary.symLinkError += 1
+					if symErr2 != nil {
+						safePrintf("SYMMIS: Syslink read error [%s%s] \n",
+						fsB.URI(), backupChildItemPath)
+						emitResult("SYMMIS", sigilError, backupChildItemPath, symErr2.Error(), 0, 0)
+
+						atomic.AddInt64(&globalResultSummary.symLinkError, 1)
 						return
 					}
 
 					// SYMLINK MISMATCH
 					if symlink1 != symlink2 {
-						fmt.Printf("SYMMIS: Syslink mismatch [%s] and [%s] \n",
-							origChildItemPath,
-							backupChildItemPath)
+						safePrintf("SYMMIS: Syslink mismatch [%s%s] and [%s%s] \n",
+							fsA.URI(), origChildItemPath,
+							fsB.URI(), backupChildItemPath)
+						emitResult("SYMMIS", sigilSymlink, origChildItemPath, "symlink-target-differs", 0, 0)
 
 
 						// Count the missing file or directory.
-						globalResultSummary.diffCount += 1
-						globalResultSummary.symMisMatch += 1
-
-						// If the orignal symlink was a directory, then the 
-						// backup is missing that directory, PLUS all of that 
-						// directory's contents.
-
-						if file1Stat.IsDir() {
-							itemCount := countItems(origChildItemPath)
-							globalResultSummary.itemCount += itemCount
-							globalResultSummary.diffCount += itemCount
+						atomic.AddInt64(&globalResultSummary.diffCount, 1)
+						atomic.AddInt64(&globalResultSummary.symMisMatch, 1)
+
+						// If the orignal symlink was a directory, then the
+						// backup is missing that directory, PLUS all of that
+						// directory's contents. (Stat, not the Lstat above,
+						// so this follows the symlink to its target.)
+
+						if targetStat, targetErr := fsA.Stat(origChildItemPath); targetErr == nil && targetStat.IsDir() {
+							itemCount := countItems(fsA, origChildItemPath)
+							atomic.AddInt64(&globalResultSummary.itemCount, int64(itemCount))
+							atomic.AddInt64(&globalResultSummary.diffCount, int64(itemCount))
 						}
 						return
 					}
 				}
 			}
 
-			
+
 			if item.IsDir() {
-					
-			checkFileSystem(userSource1, origChildItemPath);
-			
-			visit(filepath.Join( relative, item.Name()))
+
+			checkFileSystem(fsA, relative, origChildItemPath);
+
+			visit(w, filepath.Join( relative, entry.name))
 			} else {
-				if !sameFile(origChildItemPath, backupChildItemPath) {
-					globalResultSummary.diffCount += 1;
-					fmt.Printf( "FILE: [%s] not found at," +
-						"or doesn't match [%s] \n", origChildItemPath,
-						backupChildItemPath)
-				}
+				w.enqueueCompare(origChildItemPath, backupChildItemPath)
 			}
 		} // end for loop
 	}
 
 }
 
-func compare(relative string) {
-	visit(relative)
+func compare(fsA, fsB Filesystem, relative string) {
+	newWalker(fsA, fsB).run(relative)
 }
 
-func checkFileSystem(fileA, fileB string) {
+func checkFileSystem(fs Filesystem, fileA, fileB string) {
 
-	outerDevStat, outerDevStatErr := os.Stat(fileA)
-	innerDevStat, innerDevStatErr := os.Stat(fileB)
+	outerDevStat, outerDevStatErr := fs.Stat(fileA)
+	innerDevStat, innerDevStatErr := fs.Stat(fileB)
 
 	if outerDevStatErr != nil {
-		globalResultSummary.skippedCount += 1
-		fmt.Print(outerDevStatErr)
+		atomic.AddInt64(&globalResultSummary.skippedCount, 1)
+		safePrint(outerDevStatErr)
 		return
 	}
-	
+
 	if innerDevStatErr != nil {
-		globalResultSummary.skippedCount += 1
-		fmt.Print(innerDevStatErr)
+		atomic.AddInt64(&globalResultSummary.skippedCount, 1)
+		safePrint(innerDevStatErr)
 		return
 	}
 
-	outerDev := outerDevStat.Sys().(*syscall.Stat_t).Dev
-	innerDev := innerDevStat.Sys().(*syscall.Stat_t).Dev
+	// Only local filesystems expose a syscall.Stat_t; other backends have
+	// no notion of "device", so there's nothing to compare.
+	outerDevSys, outerOK := outerDevStat.Sys().(*syscall.Stat_t)
+	innerDevSys, innerOK := innerDevStat.Sys().(*syscall.Stat_t)
+
+	if !outerOK || !innerOK {
+		return
+	}
 
-	if outerDev != innerDev && *oneFilesystem {
-		globalResultSummary.skippedCount += 1
-		fmt.Printf("DIFFERS: [%s] is on a different file system than [%s]." +
+	if outerDevSys.Dev != innerDevSys.Dev && *oneFilesystem {
+		atomic.AddInt64(&globalResultSummary.skippedCount, 1)
+		safePrintf("DIFFERS: [%s%s] is on a different file system than [%s%s]." +
 			"Skipped \n",
-			fileA,
-			fileB)
+			fs.URI(), fileA,
+			fs.URI(), fileB)
 		return
 	}
 }
 
-func sameFile(fileA, fileB string) bool {
+func sameFile(fsA, fsB Filesystem, fileA, fileB string) bool {
 
 	// both files exists
-	fileAOK, fileAErr := doesFileExist(fileA)
-	fileBOK, fileBErr := doesFileExist(fileB)
+	fileAOK, fileAErr := doesFileExist(fsA, fileA)
+	fileBOK, fileBErr := doesFileExist(fsB, fileB)
 
 	if fileAOK != true &&
 		fileAErr != nil &&
 		fileBOK != true &&
 		fileBErr != nil {
-	
-		globalResultSummary.errorCount += 1
+
+		atomic.AddInt64(&globalResultSummary.errorCount, 1)
 		return false
 	}
 
 	// both files are the same size
-	fileASize, aSizeErr := getFileSize(fileA)
-	fileBSize, bSizeErr := getFileSize(fileB)
+	fileASize, aSizeErr := getFileSize(fsA, fileA)
+	fileBSize, bSizeErr := getFileSize(fsB, fileB)
 
 	if aSizeErr != nil {
-		fmt.Print("Can't get file size for" + aSizeErr.Error() + "\n")
-		globalResultSummary.errorCount += 1
+		safePrint("Can't get file size for" + aSizeErr.Error() + "\n")
+		atomic.AddInt64(&globalResultSummary.errorCount, 1)
 		return false
 	}
-	
+
 	if bSizeErr != nil {
-		fmt.Print("Can't get file size for" + bSizeErr.Error() + "\n")
-		globalResultSummary.errorCount += 1
+		safePrint("Can't get file size for" + bSizeErr.Error() + "\n")
+		atomic.AddInt64(&globalResultSummary.errorCount, 1)
 		return false
 	}
-	
+
 	if fileASize != fileBSize {
-		globalResultSummary.errorCount += 1
+		atomic.AddInt64(&globalResultSummary.errorCount, 1)
 		return false
 	}
 
-	// read both files
-	f1, f1err := os.Open(fileA)
-	f2, f2err := os.Open(fileB)
+	if *hashAlgo != hashNone {
+		return compareByHash(fsA, fsB, fileA, fileB, fileASize)
+	}
 
-	defer f1.Close()
-	defer f2.Close()
+	// read both files
+	f1, f1err := fsA.Open(fileA)
+	f2, f2err := fsB.Open(fileB)
 
 	if f1err != nil {
-		fmt.Print(f1err)
-		globalResultSummary.errorCount += 1
+		safePrint(f1err)
+		atomic.AddInt64(&globalResultSummary.errorCount, 1)
 		return false
 	}
 
 	if f2err != nil {
-		fmt.Print(f2err)
-		globalResultSummary.errorCount += 1
+		safePrint(f2err)
+		atomic.AddInt64(&globalResultSummary.errorCount, 1)
 		return false
 	}
 
+	defer f1.Close()
+	defer f2.Close()
+
 	same := true
-	
+
 	// random sample test
 	for i := int64(0); i < *sampleCount; i++ {
 		startAtByte, randErr := getRandomNumberWithMax(fileASize)
@@ -339,12 +396,12 @@ func sameFile(fileA, fileB string) bool {
 		if randErr != nil {
 			log.Fatal(randErr)
 		}
-		
+
 		// get a random number of bytes to test...
 		testBytesLength := math.Min(float64(fileASize),
 			(float64(startAtByte) + float64(sampleSize))) -
 									float64(startAtByte) + 1.0
-		
+
 		aSample := make([]byte, int(testBytesLength))
 		bSample := make([]byte, int(testBytesLength))
 
@@ -358,21 +415,21 @@ func sameFile(fileA, fileB string) bool {
 
 		// file data is different
 		if f1ReadErr != nil {
-			fmt.Println(f1ReadErr)
-			globalResultSummary.errorCount += 1
+			safePrintln(f1ReadErr)
+			atomic.AddInt64(&globalResultSummary.errorCount, 1)
 			return false
 		}
-		
+
 		if f2ReadErr != nil {
-			fmt.Println(f2ReadErr)
-			globalResultSummary.errorCount += 1
+			safePrintln(f2ReadErr)
+			atomic.AddInt64(&globalResultSummary.errorCount, 1)
 			return false
 		}
 
 		if (f1ReadByteNum != int(testBytesLength)) &&
 	       (f2ReadByteNum != int(testBytesLength)) {
 
-			globalResultSummary.errorCount += 2
+			atomic.AddInt64(&globalResultSummary.errorCount, 2)
 			return false
 		}
 
@@ -398,9 +455,9 @@ func getRandomNumberWithMax(max int64) (int64, error) {
 	return i.Int64(), nil;
 }
 
-func getFileSize(file string) (int64, error) {
-	
-	fi, err := os.Stat(file)
+func getFileSize(fs Filesystem, file string) (int64, error) {
+
+	fi, err := fs.Stat(file)
 
 	if err != nil {
 		return 0, err
@@ -409,23 +466,23 @@ func getFileSize(file string) (int64, error) {
 	return fi.Size(), nil
 }
 
-func isSymLink(file string) (bool) {
+func isSymLink(fs Filesystem, file string) (bool) {
 
-	fi, err := os.Lstat(file)
+	fi, err := fs.Lstat(file)
 
 	if *verbose {
-		fmt.Print( "\nin isSymlink" + file + "\n")
+		safePrint( "\nin isSymlink" + file + "\n")
 	}
 
 	if err != nil {
-		fmt.Println(err)
+		safePrintln(err)
 		return false
 	}
 
 	if fi.Mode() & os.ModeSymlink == os.ModeSymlink {
 		return true
 	}
-	
+
 	return false
 
 }
@@ -433,10 +490,10 @@ func isSymLink(file string) (bool) {
 
 // This function returns true and nil as the returned values, only if the file
 // exists. And the function did not return any type of error.
-// it returns any other error if the error returned by os.Stat is not the
+// it returns any other error if the error returned by Stat is not the
 // expected "does not exist" error.
-func doesFileExist(file string) (bool, error) {
-	if _, err := os.Stat(file); err != nil {
+func doesFileExist(fs Filesystem, file string) (bool, error) {
+	if _, err := fs.Stat(file); err != nil {
 		if os.IsNotExist(err) {
 			return false, nil
 		} else {
@@ -461,29 +518,29 @@ func isDirOrFile(fi os.FileInfo) string {
 	return ""
 }
 
-func countItems(dir string) int {
+func countItems(fs Filesystem, dir string) int {
 	if *verbose {
-		fmt.Printf("DEBUG: Counting files in [%s]", dir)
+		safePrintf("DEBUG: Counting files in [%s%s]", fs.URI(), dir)
 	}
 
 	count := 0
 
-	dirItems, folderErr := ioutil.ReadDir(dir)
+	dirItems, folderErr := fs.ReadDir(dir)
 
 	if folderErr != nil {
-		fmt.Printf("Unable to read %s, the error was %s",
-		dir,
+		safePrintf("Unable to read %s%s, the error was %s",
+		fs.URI(), dir,
 		folderErr.Error() +"\n")
-		
-		globalResultSummary.errorCount += 1
+
+		atomic.AddInt64(&globalResultSummary.errorCount, 1)
 	}
 
 	for _, f := range dirItems {
 		count += 1
-		
+
 		if f.IsDir() {
 			fullPath := filepath.Join(dir, f.Name())
-			count += countItems(fullPath)
+			count += countItems(fs, fullPath)
 		}
 	}
 	return count
@@ -495,9 +552,9 @@ func printSummary() {
 		float64(globalResultSummary.itemCount) * 100));
 
 	if *machine {
-		fmt.Print(globalResultSummary.getMachineText());
+		safePrint(globalResultSummary.getMachineText());
 	} else {
-		fmt.Print(globalResultSummary.getHumanReadableText())
+		safePrint(globalResultSummary.getHumanReadableText())
 	}
 }
 
@@ -513,56 +570,115 @@ differences. It compares files by size and **optionally** by a random sample of
 contents. The results are summarized into a difference percentage so it can be
 used to easily determine if a backup is valid and recent.
 
+<origDir> and <backupDir> may be plain paths (treated as file://) or URIs
+with an explicit backend: file://, fake://, tar://, zip://. (s3://, sftp://
+are reserved but not implemented yet.)
+
 Options:
   -v, --verbose                    Print what is being done
   -m, --machine                    Output summary in machine-readable format
   -x, --one-filesystem             Stay on one file system (in <original>)
   -s, --samples COUNT              Comparison sample count [default: 0]
+      --case-sensitive-fs=MODE     Is <backupDir> case-sensitive? auto|true|false [default: auto]
+  -j, --jobs N                     Parallel comparison workers [default: NumCPU]
+      --io-limit N                 Max comparisons with files open at once, 0 = unlimited
+      --hash=ALGO                  Full-hash comparison: none|xxh64|blake3|sha256 [default: none]
+      --manifest FILE              Cache original-side hashes across runs (used with --hash)
+      --output=FORMAT              Result output format: text|json [default: text]
+      --combined FILE              Write every result as a sigil-prefixed path to FILE
+      --missing-on-dst FILE        Write paths missing from <backupDir> to FILE
+      --differ FILE                Write paths that differ to FILE
+      --match FILE                 Write paths that match to FILE
+      --error FILE                 Write paths that errored to FILE
+      --two-way                    Also report files that exist in <backupDir> but not in <original>
+      --checkpoint FILE            On Ctrl-C, write resultSummary and processed paths here as JSON
+      --resume FILE                Resume a previous run: skip paths --checkpoint already recorded
+      --progress=MODE              Show files/sec, MB/sec, ETA on stderr: auto|always|never [default: auto]
   -h, --help                       Display this screen`
 
 
 	flag.Parse()
-	
+
 	if *help {
-		fmt.Print(usage)
-		fmt.Println();
+		safePrint(usage)
+		safePrintln();
 		os.Exit(1)
 	}
 
 	cmdLineStuff := flag.Args()
-	
+
 	if len(cmdLineStuff) < 2 {
 		log.Fatal("You must specify original and backup folders.")
 	}
-	
+
 	sig := make(chan os.Signal, 1)
     signal.Notify(sig, os.Interrupt, os.Kill)
 
+	go func() {
+		<-sig
+		safePrintln("\nINTERRUPT: caught signal, flushing checkpoint...")
+
+		if checkpointErr := writeCheckpoint(); checkpointErr != nil {
+			safePrintf("ERROR: could not write checkpoint %s: %s\n", *checkpointPath, checkpointErr.Error())
+		}
+
+		os.Exit(130)
+	}()
+
 	userSource1 = cmdLineStuff[0]
 	userSource2 = cmdLineStuff[1]
 
+	fsA, fsAErr := openFilesystem(userSource1)
+
+	if fsAErr != nil {
+		log.Fatalf("%s: %s", userSource1, fsAErr.Error())
+	}
+
+	fsB, fsBErr := openFilesystem(userSource2)
+
+	if fsBErr != nil {
+		log.Fatalf("%s: %s", userSource2, fsBErr.Error())
+	}
+
 	// Does these locations exist?
-	origDirStats, statErr := os.Stat(userSource1)
-	backupDirStats, statErr2 := os.Stat(userSource2)
-	
+	origDirStats, statErr := fsA.Stat("")
+	backupDirStats, statErr2 := fsB.Stat("")
+
 	if(statErr != nil) {
-		log.Fatalf("%s cannot be read", userSource1)
+		log.Fatalf("%s cannot be read", fsA.URI())
 	}
-	
+
 	if(statErr2 != nil) {
-		log.Fatalf("%s cannot be read", userSource2)
+		log.Fatalf("%s cannot be read", fsB.URI())
 	}
 
 	if isDirOrFile(origDirStats) != "directory" {
-		log.Fatalf("%s is not a directory.", userSource1)
+		log.Fatalf("%s is not a directory.", fsA.URI())
 	}
-	
+
 	if isDirOrFile(backupDirStats) != "directory" {
-		log.Fatalf("%s is not a directory.", userSource2)
+		log.Fatalf("%s is not a directory.", fsB.URI())
 	}
 
+	if loadErr := loadManifest(); loadErr != nil {
+		log.Fatalf("Cannot read manifest %s: %s", *manifestPath, loadErr.Error())
+	}
+
+	if resumeErr := loadCheckpoint(); resumeErr != nil {
+		log.Fatalf("Cannot read checkpoint %s: %s", *resumePath, resumeErr.Error())
+	}
 
-	 compare("")
+	if sinkErr := openOutputSinks(); sinkErr != nil {
+		log.Fatalf("Cannot open output file: %s", sinkErr.Error())
+	}
+
+	defer closeOutputSinks()
+
+	 compare(fsA, fsB, "")
 	 printSummary();
 
+	if saveErr := saveManifest(); saveErr != nil {
+		log.Fatalf("Cannot write manifest %s: %s", *manifestPath, saveErr.Error())
+	}
+
 }
@@ -0,0 +1,152 @@
+package main
+
+/*
+* Exercises visit()/sameFile() against fakeFS pairs -- the in-memory
+* backend fakefs.go exists specifically to make this possible without
+* touching the real disk.
+*/
+
+import "testing"
+
+// resetGlobalState clears globalResultSummary and the --resume
+// bookkeeping between test cases; both are process-global singletons
+// that production code never needs to reset mid-run.
+func resetGlobalState() {
+	globalResultSummary = new(resultSummary)
+
+	processedMu.Lock()
+	processedPaths = make(map[string]bool)
+	processedMu.Unlock()
+}
+
+func TestVisitMatch(t *testing.T) {
+	resetGlobalState()
+
+	orig := newFakeFS("orig")
+	orig.Put("a.txt", false, []byte("hello"))
+
+	backup := newFakeFS("backup")
+	backup.Put("a.txt", false, []byte("hello"))
+
+	compare(orig, backup, "")
+
+	if globalResultSummary.itemCount != 1 {
+		t.Errorf("itemCount = %d, want 1", globalResultSummary.itemCount)
+	}
+
+	if globalResultSummary.diffCount != 0 {
+		t.Errorf("diffCount = %d, want 0", globalResultSummary.diffCount)
+	}
+}
+
+func TestVisitMissingOnBackup(t *testing.T) {
+	resetGlobalState()
+
+	orig := newFakeFS("orig")
+	orig.Put("a.txt", false, []byte("hello"))
+
+	backup := newFakeFS("backup")
+
+	compare(orig, backup, "")
+
+	if globalResultSummary.diffCount != 1 {
+		t.Errorf("diffCount = %d, want 1", globalResultSummary.diffCount)
+	}
+}
+
+func TestVisitSizeMismatch(t *testing.T) {
+	resetGlobalState()
+
+	orig := newFakeFS("orig")
+	orig.Put("a.txt", false, []byte("hello"))
+
+	backup := newFakeFS("backup")
+	backup.Put("a.txt", false, []byte("hello there"))
+
+	compare(orig, backup, "")
+
+	if globalResultSummary.diffCount != 1 {
+		t.Errorf("diffCount = %d, want 1", globalResultSummary.diffCount)
+	}
+}
+
+func TestVisitExtraOnBackupIgnoredByDefault(t *testing.T) {
+	resetGlobalState()
+
+	orig := newFakeFS("orig")
+	orig.Put("a.txt", false, []byte("hello"))
+
+	backup := newFakeFS("backup")
+	backup.Put("a.txt", false, []byte("hello"))
+	backup.Put("extra.txt", false, []byte("surprise"))
+
+	compare(orig, backup, "")
+
+	if globalResultSummary.extraCount != 0 {
+		t.Errorf("extraCount = %d, want 0 (--two-way is off)", globalResultSummary.extraCount)
+	}
+}
+
+func TestVisitExtraOnBackupTwoWay(t *testing.T) {
+	resetGlobalState()
+
+	orig := newFakeFS("orig")
+	orig.Put("a.txt", false, []byte("hello"))
+
+	backup := newFakeFS("backup")
+	backup.Put("a.txt", false, []byte("hello"))
+	backup.Put("extra.txt", false, []byte("surprise"))
+
+	old := *twoWay
+	*twoWay = true
+	defer func() { *twoWay = old }()
+
+	compare(orig, backup, "")
+
+	if globalResultSummary.extraCount != 1 {
+		t.Errorf("extraCount = %d, want 1", globalResultSummary.extraCount)
+	}
+}
+
+func TestVisitNestedDirectory(t *testing.T) {
+	resetGlobalState()
+
+	orig := newFakeFS("orig")
+	orig.Put("dir", true, nil)
+	orig.Put("dir/nested.txt", false, []byte("hello"))
+
+	backup := newFakeFS("backup")
+	backup.Put("dir", true, nil)
+	backup.Put("dir/nested.txt", false, []byte("hello"))
+
+	compare(orig, backup, "")
+
+	// dir + dir/nested.txt
+	if globalResultSummary.itemCount != 2 {
+		t.Errorf("itemCount = %d, want 2", globalResultSummary.itemCount)
+	}
+
+	if globalResultSummary.diffCount != 0 {
+		t.Errorf("diffCount = %d, want 0", globalResultSummary.diffCount)
+	}
+}
+
+func TestPutCreatesParentsImplicitly(t *testing.T) {
+	resetGlobalState()
+
+	orig := newFakeFS("orig")
+	orig.Put("dir/nested.txt", false, []byte("hello")) // no explicit Put("dir", ...)
+
+	backup := newFakeFS("backup")
+	backup.Put("dir/nested.txt", false, []byte("hello"))
+
+	compare(orig, backup, "")
+
+	if globalResultSummary.itemCount != 2 {
+		t.Errorf("itemCount = %d, want 2 (dir + dir/nested.txt)", globalResultSummary.itemCount)
+	}
+
+	if globalResultSummary.diffCount != 0 {
+		t.Errorf("diffCount = %d, want 0", globalResultSummary.diffCount)
+	}
+}